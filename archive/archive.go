@@ -0,0 +1,442 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// magic identifies a disk-hog-backup archive file and lets Extract/Verify
+// fail fast on anything else.
+var magic = [4]byte{'D', 'H', 'B', '1'}
+
+// ArchiveOptions configures how Archive builds a .dhb file.
+type ArchiveOptions struct {
+	// Compress gzip-compresses the tar stream. Archives are rarely useful
+	// uncompressed, but the option exists for callers backing up content
+	// that's already compressed (e.g. media libraries).
+	Compress bool
+
+	Encrypt    bool
+	Passphrase string
+}
+
+// ExtractOptions configures Extract and Verify.
+type ExtractOptions struct {
+	Passphrase string
+}
+
+// Archive walks source and writes it as a single destFile: a manifest
+// (format version, source root, creation time, per-entry SHA-256, total
+// size) followed by a tar stream, optionally gzip-compressed and/or
+// encrypted with a passphrase.
+func Archive(source, destFile string, opts ArchiveOptions) error {
+	out, err := os.Create(destFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := out.Write(magic[:]); err != nil {
+		return err
+	}
+
+	var salt []byte
+	if opts.Encrypt {
+		salt, err = newSalt()
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write([]byte{1}); err != nil {
+			return err
+		}
+	} else {
+		salt = make([]byte, saltSize)
+		if _, err := out.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	if _, err := out.Write(salt); err != nil {
+		return err
+	}
+
+	var payload io.Writer = out
+	var closer io.Closer
+	if opts.Encrypt {
+		cw, err := newChunkWriter(out, deriveKey(opts.Passphrase, salt))
+		if err != nil {
+			return err
+		}
+		payload = cw
+		closer = cw
+	}
+
+	manifest, entries, err := buildManifest(source)
+	if err != nil {
+		return err
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	var lenPrefix [8]byte
+	binary.BigEndian.PutUint64(lenPrefix[:], uint64(len(manifestJSON)))
+	if _, err := payload.Write(lenPrefix[:]); err != nil {
+		return err
+	}
+	if _, err := payload.Write(manifestJSON); err != nil {
+		return err
+	}
+
+	if err := writeTar(payload, source, entries, opts.Compress); err != nil {
+		return err
+	}
+
+	if closer != nil {
+		return closer.Close()
+	}
+	return nil
+}
+
+// buildManifest walks source and hashes every regular file, without
+// writing anything, so Archive can emit the manifest before the tar
+// stream that follows it.
+func buildManifest(source string) (Manifest, []string, error) {
+	manifest := Manifest{
+		FormatVersion: manifestFormatVersion,
+		SourceRoot:    source,
+		CreatedAt:     time.Now(),
+	}
+	var paths []string
+
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			Path:   rel,
+			Size:   info.Size(),
+			SHA256: hash,
+		})
+		manifest.TotalSize += info.Size()
+		paths = append(paths, rel)
+		return nil
+	})
+	return manifest, paths, err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeTar writes every path under source into w as a tar stream (relative
+// paths as recorded in entries), optionally gzip-compressed.
+func writeTar(w io.Writer, source string, entries []string, compress bool) error {
+	var tw *tar.Writer
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(w)
+		tw = tar.NewWriter(gz)
+	} else {
+		tw = tar.NewWriter(w)
+	}
+
+	for _, rel := range entries {
+		path := filepath.Join(source, rel)
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if err := copyFileInto(tw, path); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+func copyFileInto(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// openPayload reads and validates an archive's header from f and returns a
+// reader positioned at the start of the (possibly decrypted) manifest +
+// tar payload.
+func openPayload(f *os.File, opts ExtractOptions) (io.Reader, error) {
+	var gotMagic [4]byte
+	if _, err := io.ReadFull(f, gotMagic[:]); err != nil {
+		return nil, fmt.Errorf("archive: failed to read header: %w", err)
+	}
+	if gotMagic != magic {
+		return nil, fmt.Errorf("archive: not a disk-hog-backup archive")
+	}
+
+	var encFlag [1]byte
+	if _, err := io.ReadFull(f, encFlag[:]); err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(f, salt); err != nil {
+		return nil, err
+	}
+
+	if encFlag[0] == 0 {
+		return f, nil
+	}
+
+	if opts.Passphrase == "" {
+		return nil, fmt.Errorf("archive: archive is encrypted, passphrase required")
+	}
+	cr, err := newChunkReader(f, deriveKey(opts.Passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// readManifest reads the manifest that precedes the tar stream in r,
+// returning a reader positioned at the start of the tar stream.
+func readManifest(r io.Reader) (Manifest, io.Reader, error) {
+	var lenPrefix [8]byte
+	if _, err := io.ReadFull(r, lenPrefix[:]); err != nil {
+		return Manifest{}, nil, err
+	}
+	manifestJSON := make([]byte, binary.BigEndian.Uint64(lenPrefix[:]))
+	if _, err := io.ReadFull(r, manifestJSON); err != nil {
+		return Manifest{}, nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestJSON, &manifest); err != nil {
+		return Manifest{}, nil, err
+	}
+	return manifest, r, nil
+}
+
+// safeJoin joins destDir with name (a tar entry path) and verifies the
+// result stays within destDir. Archives are untrusted input: a crafted
+// entry name like "../../../etc/cron.d/evil" or an absolute path would
+// otherwise let Extract write outside the destination directory (tar
+// slip).
+func safeJoin(destDir, name string) (string, error) {
+	joined := filepath.Join(destDir, filepath.FromSlash(name))
+	destDir = filepath.Clean(destDir)
+	if joined != destDir && !strings.HasPrefix(joined, destDir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive: entry %q escapes destination directory", name)
+	}
+	return joined, nil
+}
+
+// Extract decodes archiveFile and writes its contents under destDir.
+func Extract(archiveFile, destDir string, opts ExtractOptions) error {
+	f, err := os.Open(archiveFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	payload, err := openPayload(f, opts)
+	if err != nil {
+		return err
+	}
+	_, tarStream, err := readManifest(payload)
+	if err != nil {
+		return err
+	}
+
+	tr, closeTar, err := tarReader(tarStream)
+	if err != nil {
+		return err
+	}
+	defer closeTar()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		destPath, err := safeJoin(destDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return err
+		}
+		out.Close()
+		if err := os.Chtimes(destPath, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+}
+
+// Verify stream-reads archiveFile and checks every entry's content hash
+// against the manifest, without extracting anything to disk.
+func Verify(archiveFile string, opts ExtractOptions) error {
+	f, err := os.Open(archiveFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	payload, err := openPayload(f, opts)
+	if err != nil {
+		return err
+	}
+	manifest, tarStream, err := readManifest(payload)
+	if err != nil {
+		return err
+	}
+
+	want := make(map[string]ManifestEntry, len(manifest.Entries))
+	for _, e := range manifest.Entries {
+		want[filepath.ToSlash(e.Path)] = e
+	}
+
+	tr, closeTar, err := tarReader(tarStream)
+	if err != nil {
+		return err
+	}
+	defer closeTar()
+
+	seen := make(map[string]bool, len(manifest.Entries))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		entry, ok := want[hdr.Name]
+		if !ok {
+			return fmt.Errorf("archive: entry %q not present in manifest", hdr.Name)
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(h, tr); err != nil {
+			return err
+		}
+		gotHash := hex.EncodeToString(h.Sum(nil))
+		if gotHash != entry.SHA256 {
+			return fmt.Errorf("archive: entry %q hash mismatch: manifest says %s, got %s", hdr.Name, entry.SHA256, gotHash)
+		}
+		seen[hdr.Name] = true
+	}
+
+	for path := range want {
+		if !seen[path] {
+			return fmt.Errorf("archive: entry %q in manifest but missing from archive", path)
+		}
+	}
+	return nil
+}
+
+// tarReader returns a *tar.Reader over r, transparently gunzipping first
+// if r looks gzip-compressed. The returned close func releases the gzip
+// reader, if one was created.
+func tarReader(r io.Reader) (*tar.Reader, func(), error) {
+	br := &peekReader{r: r}
+	magic, err := br.peek(2)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(gz), func() { gz.Close() }, nil
+	}
+	return tar.NewReader(br), func() {}, nil
+}
+
+// peekReader lets tarReader inspect the first couple of bytes of r (to
+// detect a gzip header) without consuming them from the stream.
+type peekReader struct {
+	r       io.Reader
+	peeked  []byte
+	peekPos int
+}
+
+func (p *peekReader) peek(n int) ([]byte, error) {
+	if len(p.peeked) >= n {
+		return p.peeked[:n], nil
+	}
+	buf := make([]byte, n)
+	read, err := io.ReadFull(p.r, buf)
+	p.peeked = buf[:read]
+	return p.peeked, err
+}
+
+func (p *peekReader) Read(buf []byte) (int, error) {
+	if p.peekPos < len(p.peeked) {
+		n := copy(buf, p.peeked[p.peekPos:])
+		p.peekPos += n
+		return n, nil
+	}
+	return p.r.Read(buf)
+}
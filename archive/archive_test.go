@@ -0,0 +1,171 @@
+package archive
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeMaliciousArchive hand-assembles a .dhb file whose single tar entry
+// is named entryName, bypassing Archive (which always emits safe,
+// source-relative names) so Extract's path-traversal guard can be
+// exercised directly.
+func writeMaliciousArchive(t *testing.T, archiveFile, entryName string) {
+	t.Helper()
+	out, err := os.Create(archiveFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	if _, err := out.Write(magic[:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.Write([]byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.Write(make([]byte, saltSize)); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := Manifest{
+		FormatVersion: manifestFormatVersion,
+		Entries: []ManifestEntry{
+			{Path: entryName, Size: 5},
+		},
+	}
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lenPrefix [8]byte
+	binary.BigEndian.PutUint64(lenPrefix[:], uint64(len(manifestJSON)))
+	if _, err := out.Write(lenPrefix[:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := out.Write(manifestJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tar.NewWriter(out)
+	hdr := &tar.Header{Name: entryName, Mode: 0o644, Size: 5}
+	if err := tw.WriteHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte("pwned")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeSourceTree(t *testing.T) string {
+	t.Helper()
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestArchiveExtractRoundTrip(t *testing.T) {
+	src := writeSourceTree(t)
+	dest := t.TempDir()
+	archiveFile := filepath.Join(t.TempDir(), "backup.dhb")
+
+	if err := Archive(src, archiveFile, ArchiveOptions{Compress: true}); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	if err := Extract(archiveFile, dest, ExtractOptions{}); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "sub", "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "world" {
+		t.Fatalf("got %q, want %q", data, "world")
+	}
+}
+
+func TestArchiveEncryptedRoundTrip(t *testing.T) {
+	src := writeSourceTree(t)
+	dest := t.TempDir()
+	archiveFile := filepath.Join(t.TempDir(), "backup.dhb")
+
+	opts := ArchiveOptions{Compress: true, Encrypt: true, Passphrase: "correct horse battery staple"}
+	if err := Archive(src, archiveFile, opts); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	if err := Extract(archiveFile, dest, ExtractOptions{Passphrase: "wrong passphrase"}); err == nil {
+		t.Fatal("expected extract with wrong passphrase to fail")
+	}
+
+	if err := Extract(archiveFile, dest, ExtractOptions{Passphrase: opts.Passphrase}); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("got %q, want %q", data, "hello")
+	}
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+	escapeTarget := filepath.Join(t.TempDir(), "evil.txt")
+	entryName := "../../../" + filepath.ToSlash(escapeTarget)
+
+	archiveFile := filepath.Join(t.TempDir(), "backup.dhb")
+	writeMaliciousArchive(t, archiveFile, entryName)
+
+	dest := t.TempDir()
+	if err := Extract(archiveFile, dest, ExtractOptions{}); err == nil {
+		t.Fatalf("expected Extract to reject entry %q, got nil error", entryName)
+	}
+	if _, err := os.Stat(escapeTarget); !os.IsNotExist(err) {
+		t.Fatalf("entry %q escaped destDir: found file at %q", entryName, escapeTarget)
+	}
+}
+
+func TestVerifyDetectsCorruption(t *testing.T) {
+	src := writeSourceTree(t)
+	archiveFile := filepath.Join(t.TempDir(), "backup.dhb")
+
+	if err := Archive(src, archiveFile, ArchiveOptions{Compress: true}); err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+
+	if err := Verify(archiveFile, ExtractOptions{}); err != nil {
+		t.Fatalf("expected verify to pass on an untouched archive: %v", err)
+	}
+
+	data, err := os.ReadFile(archiveFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := len(data) - 1; i > len(data)/2; i-- {
+		data[i] ^= 0xFF
+	}
+	if err := os.WriteFile(archiveFile, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(archiveFile, ExtractOptions{}); err == nil {
+		t.Fatal("expected verify to fail on a corrupted archive")
+	}
+}
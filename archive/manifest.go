@@ -0,0 +1,29 @@
+// Package archive implements disk-hog-backup's single-file portable backup
+// format: a gzip-compressed tar stream preceded by a JSON manifest, with an
+// optional passphrase-derived encryption layer wrapped around the whole
+// thing.
+package archive
+
+import "time"
+
+// manifestFormatVersion is bumped whenever the on-disk manifest or archive
+// layout changes incompatibly.
+const manifestFormatVersion = 1
+
+// Manifest describes the contents of a .dhb archive: where it came from,
+// when it was made, and a per-entry content hash so Verify can check
+// integrity without extracting.
+type Manifest struct {
+	FormatVersion int             `json:"format_version"`
+	SourceRoot    string          `json:"source_root"`
+	CreatedAt     time.Time       `json:"created_at"`
+	TotalSize     int64           `json:"total_size"`
+	Entries       []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry records one file's identity within the archive.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
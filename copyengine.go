@@ -0,0 +1,245 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SymlinkPolicy controls how a CopyEngine treats symbolic links.
+type SymlinkPolicy int
+
+const (
+	// SymlinkSkip omits symlinks from the copy entirely.
+	SymlinkSkip SymlinkPolicy = iota
+	// SymlinkShallow recreates the link itself, pointing at the same target.
+	SymlinkShallow
+	// SymlinkFollow copies the file or directory the link points to.
+	SymlinkFollow
+)
+
+// DirExistsPolicy controls what happens when the destination directory for
+// a copy already exists.
+type DirExistsPolicy int
+
+const (
+	// DirMerge copies into the existing directory, leaving unrelated
+	// existing entries alone.
+	DirMerge DirExistsPolicy = iota
+	// DirReplace removes the existing directory before copying.
+	DirReplace
+	// DirSkip leaves the existing directory untouched.
+	DirSkip
+)
+
+// PermissionPolicy controls how a copied file's mode bits are derived from
+// the source file's mode.
+type PermissionPolicy int
+
+const (
+	// PermissionPreserve copies the source file's mode bits exactly.
+	PermissionPreserve PermissionPolicy = iota
+	// PermissionAdd ORs the source mode with Options.Mode instead of
+	// replacing it, e.g. to guarantee a minimum permission such as
+	// group-readable on every copied file regardless of its source mode.
+	PermissionAdd
+	// PermissionOverride ignores the source mode and uses Options.Mode.
+	PermissionOverride
+)
+
+// Options configures a CopyEngine's behavior, modeled on the otiai10/copy
+// API so callers can pick policies per concern instead of getting one fixed
+// behavior.
+type Options struct {
+	OnSymlink   SymlinkPolicy
+	OnDirExists DirExistsPolicy
+
+	// OnError is invoked for every error encountered while copying. If it
+	// returns false, the walk stops and that error is returned from Copy;
+	// otherwise the error is recorded and the walk continues.
+	OnError func(src, dest string, err error) (continueWalk bool)
+
+	// Skip excludes an entry (and, for directories, everything under it)
+	// from the copy when it returns true.
+	Skip func(info os.FileInfo, src, dest string) bool
+
+	PermissionControl PermissionPolicy
+	// Mode is used for every copied file and directory when
+	// PermissionControl is PermissionOverride.
+	Mode os.FileMode
+
+	PreserveTimes bool
+}
+
+// DefaultOptions returns the policy this repo uses for ordinary backups:
+// symlinks are followed shallowly (recreated as links, not traversed),
+// times and mode bits are preserved, directories are merged, and sockets
+// and devices are skipped since they cannot be meaningfully copied.
+func DefaultOptions() Options {
+	return Options{
+		OnSymlink:         SymlinkShallow,
+		OnDirExists:       DirMerge,
+		PermissionControl: PermissionPreserve,
+		PreserveTimes:     true,
+		Skip: func(info os.FileInfo, src, dest string) bool {
+			return info.Mode()&(os.ModeSocket|os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe) != 0
+		},
+	}
+}
+
+// CopyEngine copies a file tree from src to dest under a configurable
+// Options policy, accumulating errors rather than aborting on the first
+// one.
+type CopyEngine interface {
+	Copy(src, dest string, opts Options) error
+}
+
+// fsCopyEngine is the CopyEngine used for local filesystem backups.
+type fsCopyEngine struct{}
+
+// NewCopyEngine returns the default local-filesystem CopyEngine.
+func NewCopyEngine() CopyEngine {
+	return fsCopyEngine{}
+}
+
+func (fsCopyEngine) Copy(src, dest string, opts Options) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return opts.handleError(src, dest, err)
+	}
+	return copyEntry(src, dest, info, opts)
+}
+
+// handleError reports err through opts.OnError if set, returning the error
+// to the caller only when the callback says not to continue (or there is
+// no callback, in which case the default is to abort).
+func (o Options) handleError(src, dest string, err error) error {
+	if o.OnError == nil {
+		return err
+	}
+	if o.OnError(src, dest, err) {
+		return nil
+	}
+	return err
+}
+
+func copyEntry(src, dest string, info os.FileInfo, opts Options) error {
+	if opts.Skip != nil && opts.Skip(info, src, dest) {
+		return nil
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return copySymlink(src, dest, info, opts)
+	case info.IsDir():
+		return copyDir(src, dest, info, opts)
+	default:
+		return copyRegular(src, dest, info, opts)
+	}
+}
+
+func copyDir(src, dest string, info os.FileInfo, opts Options) error {
+	if _, err := os.Stat(dest); err == nil {
+		switch opts.OnDirExists {
+		case DirSkip:
+			return nil
+		case DirReplace:
+			if err := os.RemoveAll(dest); err != nil {
+				return opts.handleError(src, dest, err)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dest, destMode(info, opts)); err != nil {
+		return opts.handleError(src, dest, err)
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return opts.handleError(src, dest, err)
+	}
+
+	for _, entry := range entries {
+		childSrc := filepath.Join(src, entry.Name())
+		childDest := filepath.Join(dest, entry.Name())
+
+		childInfo, err := os.Lstat(childSrc)
+		if err != nil {
+			if err := opts.handleError(childSrc, childDest, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyEntry(childSrc, childDest, childInfo, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.PreserveTimes {
+		_ = os.Chtimes(dest, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+func copySymlink(src, dest string, info os.FileInfo, opts Options) error {
+	switch opts.OnSymlink {
+	case SymlinkSkip:
+		return nil
+	case SymlinkFollow:
+		target, err := filepath.EvalSymlinks(src)
+		if err != nil {
+			return opts.handleError(src, dest, err)
+		}
+		targetInfo, err := os.Stat(target)
+		if err != nil {
+			return opts.handleError(src, dest, err)
+		}
+		return copyEntry(target, dest, targetInfo, opts)
+	default: // SymlinkShallow
+		target, err := os.Readlink(src)
+		if err != nil {
+			return opts.handleError(src, dest, err)
+		}
+		if err := os.Symlink(target, dest); err != nil {
+			return opts.handleError(src, dest, err)
+		}
+		return nil
+	}
+}
+
+func copyRegular(src, dest string, info os.FileInfo, opts Options) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return opts.handleError(src, dest, err)
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, destMode(info, opts))
+	if err != nil {
+		return opts.handleError(src, dest, err)
+	}
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, srcFile); err != nil {
+		return opts.handleError(src, dest, err)
+	}
+	destFile.Close()
+
+	if opts.PreserveTimes {
+		if err := os.Chtimes(dest, info.ModTime(), info.ModTime()); err != nil {
+			return opts.handleError(src, dest, err)
+		}
+	}
+	return nil
+}
+
+func destMode(info os.FileInfo, opts Options) os.FileMode {
+	switch opts.PermissionControl {
+	case PermissionOverride:
+		return opts.Mode
+	case PermissionAdd:
+		return info.Mode() | opts.Mode
+	default: // PermissionPreserve
+		return info.Mode()
+	}
+}
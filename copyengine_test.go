@@ -0,0 +1,349 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyEngineCopiesRegularFile(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	srcFile := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(srcFile, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	destFile := filepath.Join(dest, "a.txt")
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(srcFile, destFile, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("got %q, want %q", data, "hello")
+	}
+}
+
+func TestCopyEngineSkipPredicate(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(src, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, ".git", "config"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "keep.txt"), []byte("y"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Skip = func(info os.FileInfo, srcPath, destPath string) bool {
+		return info.Name() == ".git"
+	}
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(src, dest, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, ".git")); !os.IsNotExist(err) {
+		t.Fatal("expected .git to be excluded")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "keep.txt")); err != nil {
+		t.Fatal("expected keep.txt to be copied")
+	}
+}
+
+func TestCopyEngineSymlinkShallow(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	target := filepath.Join(src, "target.txt")
+	if err := os.WriteFile(target, []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(src, "link.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	destLink := filepath.Join(dest, "link.txt")
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(link, destLink, DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(destLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("expected shallow copy to recreate a symlink")
+	}
+}
+
+func TestCopyEngineSymlinkSkip(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	target := filepath.Join(src, "target.txt")
+	if err := os.WriteFile(target, []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(src, "link.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	destLink := filepath.Join(dest, "link.txt")
+
+	opts := DefaultOptions()
+	opts.OnSymlink = SymlinkSkip
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(link, destLink, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(destLink); !os.IsNotExist(err) {
+		t.Fatal("expected symlink to be skipped entirely")
+	}
+}
+
+func TestCopyEngineSymlinkFollow(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	target := filepath.Join(src, "target.txt")
+	if err := os.WriteFile(target, []byte("hi"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(src, "link.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	destLink := filepath.Join(dest, "link.txt")
+
+	opts := DefaultOptions()
+	opts.OnSymlink = SymlinkFollow
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(link, destLink, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat(destLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		t.Fatal("expected follow to copy the link's target, not recreate the link")
+	}
+	data, err := os.ReadFile(destLink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hi" {
+		t.Fatalf("got %q, want %q", data, "hi")
+	}
+}
+
+func TestCopyEngineDirMerge(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(src, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "existing.txt"), []byte("existing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.OnDirExists = DirMerge
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(src, dest, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "existing.txt")); err != nil {
+		t.Fatal("expected pre-existing file to survive a merge")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "new.txt")); err != nil {
+		t.Fatal("expected new file to be copied into the existing directory")
+	}
+}
+
+func TestCopyEngineDirReplace(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(src, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "stale.txt"), []byte("stale"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.OnDirExists = DirReplace
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(src, dest, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "stale.txt")); !os.IsNotExist(err) {
+		t.Fatal("expected existing directory to be wiped before copying")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "new.txt")); err != nil {
+		t.Fatal("expected new file to be copied after replace")
+	}
+}
+
+func TestCopyEngineDirSkip(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(src, "new.txt"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "untouched.txt"), []byte("untouched"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.OnDirExists = DirSkip
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(src, dest, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "new.txt")); !os.IsNotExist(err) {
+		t.Fatal("expected DirSkip to leave the existing directory untouched")
+	}
+	if _, err := os.Stat(filepath.Join(dest, "untouched.txt")); err != nil {
+		t.Fatal("expected pre-existing file to remain")
+	}
+}
+
+func TestCopyEnginePermissionPreserve(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	srcFile := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(srcFile, []byte("hello"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	destFile := filepath.Join(dest, "a.txt")
+
+	opts := DefaultOptions()
+	opts.PermissionControl = PermissionPreserve
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(srcFile, destFile, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Fatalf("got mode %v, want %v", info.Mode().Perm(), os.FileMode(0o640))
+	}
+}
+
+func TestCopyEnginePermissionAdd(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	srcFile := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(srcFile, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	destFile := filepath.Join(dest, "a.txt")
+
+	opts := DefaultOptions()
+	opts.PermissionControl = PermissionAdd
+	opts.Mode = 0o044
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(srcFile, destFile, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Fatalf("got mode %v, want %v", info.Mode().Perm(), os.FileMode(0o644))
+	}
+}
+
+func TestCopyEnginePermissionOverride(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	srcFile := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(srcFile, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	destFile := filepath.Join(dest, "a.txt")
+
+	opts := DefaultOptions()
+	opts.PermissionControl = PermissionOverride
+	opts.Mode = 0o444
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(srcFile, destFile, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(destFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o444 {
+		t.Fatalf("got mode %v, want %v", info.Mode().Perm(), os.FileMode(0o444))
+	}
+}
+
+func TestCopyEngineOnErrorContinues(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(src, "ok.txt"), []byte("ok"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(src, "missing.txt"), filepath.Join(src, "dangling.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []error
+	opts := DefaultOptions()
+	opts.OnSymlink = SymlinkFollow
+	opts.OnError = func(src, dest string, err error) bool {
+		errs = append(errs, err)
+		return true
+	}
+
+	engine := NewCopyEngine()
+	if err := engine.Copy(src, dest, opts); err != nil {
+		t.Fatalf("expected OnError to swallow failures, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "ok.txt")); err != nil {
+		t.Fatal("expected ok.txt to still be copied")
+	}
+}
@@ -0,0 +1,77 @@
+package destination
+
+import (
+	"errors"
+	"io"
+	"path"
+)
+
+// CopyTree recursively copies every entry under srcPath in src to destPath
+// in dest. It is the Destination-generic counterpart to CopyEngine: it
+// works for any Source/Destination pair (local-to-S3, SFTP-to-local, and
+// so on), at the cost of the local-only optimizations CopyEngine and the
+// snapshot hard-link index rely on.
+func CopyTree(src Source, srcPath string, dest Destination, destPath string) error {
+	info, err := src.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(src, srcPath, dest, destPath)
+	}
+
+	if err := dest.Mkdir(destPath); err != nil {
+		return err
+	}
+	entries, err := src.List(srcPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		childSrc := path.Join(srcPath, entry.Name())
+		childDest := path.Join(destPath, entry.Name())
+		if entry.IsDir() {
+			if err := CopyTree(src, childSrc, dest, childDest); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyFile(src, childSrc, dest, childDest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src Source, srcPath string, dest Destination, destPath string) error {
+	r, err := src.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := dest.Create(destPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// LinkOrCopy hard-links newPath to oldPath on dest when supported, falling
+// back to a full copy from src when the destination has no hard-link
+// equivalent (e.g. S3).
+func LinkOrCopy(src Source, oldPath string, dest Destination, newPath string) error {
+	err := dest.Link(oldPath, newPath)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ErrLinkUnsupported) {
+		return err
+	}
+	return copyFile(src, oldPath, dest, newPath)
+}
@@ -0,0 +1,73 @@
+package destination
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyTreeRecursesIntoDirectories(t *testing.T) {
+	srcRoot := t.TempDir()
+	destRoot := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(srcRoot, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcRoot, "sub", "file.txt"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	src := NewLocalFS(srcRoot)
+	dest := NewLocalFS(destRoot)
+
+	if err := CopyTree(src, ".", dest, "."); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(destRoot, "sub", "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("got %q, want %q", data, "data")
+	}
+}
+
+// noLinkDestination wraps LocalFS but always reports hard links as
+// unsupported, to exercise LinkOrCopy's fallback path.
+type noLinkDestination struct {
+	*LocalFS
+}
+
+func (noLinkDestination) Link(string, string) error { return ErrLinkUnsupported }
+
+func TestLinkOrCopyFallsBackWhenUnsupported(t *testing.T) {
+	root := t.TempDir()
+	fs := NewLocalFS(root)
+	wrapped := noLinkDestination{fs}
+
+	w, err := fs.Create("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("content"))
+	w.Close()
+
+	if err := LinkOrCopy(fs, "a.txt", wrapped, "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := fs.Open("b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "content" {
+		t.Fatalf("got %q, want %q", data, "content")
+	}
+}
@@ -0,0 +1,41 @@
+// Package destination abstracts where a backup's bytes end up (and where
+// a restore reads them from) behind a small interface, so the copy path
+// isn't hard-wired to the local filesystem. Selection is driven by a URL
+// scheme: a plain path is local, sftp://user@host/path talks to an SFTP
+// server, and s3://bucket/prefix talks to an S3-compatible object store.
+package destination
+
+import (
+	"io"
+	"os"
+)
+
+// Destination is anywhere a backup can write files: the local disk, a
+// remote SFTP server, or an S3-compatible bucket.
+type Destination interface {
+	Mkdir(path string) error
+	Create(path string) (io.WriteCloser, error)
+	Stat(path string) (os.FileInfo, error)
+	List(path string) ([]os.FileInfo, error)
+	// Link hard-links newPath to oldPath when the destination supports
+	// it. Destinations that can't (SFTP without hard-link support, S3)
+	// return ErrLinkUnsupported so callers can fall back to a copy.
+	Link(oldPath, newPath string) error
+}
+
+// Source is anywhere a backup can read files from. LocalFS implements
+// both Source and Destination so cross-destination copies (e.g. local to
+// S3, or SFTP to local) work with the same engine.
+type Source interface {
+	Open(path string) (io.ReadCloser, error)
+	Stat(path string) (os.FileInfo, error)
+	List(path string) ([]os.FileInfo, error)
+}
+
+// ErrLinkUnsupported is returned by Link on destinations with no
+// hard-link equivalent.
+var ErrLinkUnsupported = linkUnsupportedError{}
+
+type linkUnsupportedError struct{}
+
+func (linkUnsupportedError) Error() string { return "destination: hard links are not supported" }
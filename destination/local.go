@@ -0,0 +1,110 @@
+package destination
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalFS implements Destination and Source over the local filesystem,
+// rooted at Root so relative paths passed to Mkdir/Create/Stat/List/Open
+// can't escape it via "..".
+type LocalFS struct {
+	Root string
+}
+
+// NewLocalFS returns a LocalFS rooted at root.
+func NewLocalFS(root string) *LocalFS {
+	return &LocalFS{Root: root}
+}
+
+// resolve joins path onto Root and verifies the result stays within Root.
+// Entry names reaching LocalFS aren't always trusted input — CopyTree and
+// LinkOrCopy build them from a Source.List() response, which for SFTP could
+// come from a compromised or buggy remote peer — so this rejects a crafted
+// "../../etc/passwd"-style path the same way archive.safeJoin rejects a
+// tar-slip entry, rather than silently joining it.
+func (l *LocalFS) resolve(path string) (string, error) {
+	root := filepath.Clean(l.Root)
+	joined := filepath.Join(root, path)
+	rootPrefix := root
+	if !strings.HasSuffix(rootPrefix, string(os.PathSeparator)) {
+		rootPrefix += string(os.PathSeparator)
+	}
+	if joined != root && !strings.HasPrefix(joined, rootPrefix) {
+		return "", fmt.Errorf("destination: path %q escapes root %q", path, l.Root)
+	}
+	return joined, nil
+}
+
+func (l *LocalFS) Mkdir(path string) error {
+	full, err := l.resolve(path)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(full, 0o755)
+}
+
+func (l *LocalFS) Create(path string) (io.WriteCloser, error) {
+	full, err := l.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(full)
+}
+
+func (l *LocalFS) Open(path string) (io.ReadCloser, error) {
+	full, err := l.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(full)
+}
+
+func (l *LocalFS) Stat(path string) (os.FileInfo, error) {
+	full, err := l.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(full)
+}
+
+func (l *LocalFS) List(path string) ([]os.FileInfo, error) {
+	full, err := l.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (l *LocalFS) Link(oldPath, newPath string) error {
+	full, err := l.resolve(newPath)
+	if err != nil {
+		return err
+	}
+	oldFull, err := l.resolve(oldPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	return os.Link(oldFull, full)
+}
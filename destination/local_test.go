@@ -0,0 +1,124 @@
+package destination
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalFSCreateAndOpen(t *testing.T) {
+	fs := NewLocalFS(t.TempDir())
+
+	w, err := fs.Create("a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := fs.Open("a/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("got %q, want %q", data, "hello")
+	}
+}
+
+func TestLocalFSList(t *testing.T) {
+	root := t.TempDir()
+	fs := NewLocalFS(root)
+
+	if err := os.WriteFile(filepath.Join(root, "one.txt"), []byte("1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "two.txt"), []byte("2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := fs.List(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("got %d entries, want 2", len(infos))
+	}
+}
+
+func TestLocalFSRejectsPathTraversal(t *testing.T) {
+	root := t.TempDir()
+	fs := NewLocalFS(root)
+	escapeTarget := filepath.Join(filepath.Dir(root), "evil.txt")
+
+	if _, err := fs.Create("../evil.txt"); err == nil {
+		t.Fatal("expected Create to reject a path escaping Root")
+	}
+	if _, err := os.Stat(escapeTarget); !os.IsNotExist(err) {
+		t.Fatalf("path escaped Root: found file at %q", escapeTarget)
+	}
+
+	if err := fs.Mkdir("../../etc"); err == nil {
+		t.Fatal("expected Mkdir to reject a path escaping Root")
+	}
+	if _, err := fs.Open("../../../etc/passwd"); err == nil {
+		t.Fatal("expected Open to reject a path escaping Root")
+	}
+	if _, err := fs.Stat("../outside"); err == nil {
+		t.Fatal("expected Stat to reject a path escaping Root")
+	}
+	if _, err := fs.List("../"); err == nil {
+		t.Fatal("expected List to reject a path escaping Root")
+	}
+}
+
+func TestLocalFSRootSlashAcceptsContainedPaths(t *testing.T) {
+	fs := NewLocalFS(string(filepath.Separator))
+
+	full, err := fs.resolve("etc/passwd")
+	if err != nil {
+		t.Fatalf("expected a path under root %q to resolve, got: %v", string(filepath.Separator), err)
+	}
+	want := filepath.Join(string(filepath.Separator), "etc", "passwd")
+	if full != want {
+		t.Fatalf("got %q, want %q", full, want)
+	}
+}
+
+func TestLocalFSLink(t *testing.T) {
+	root := t.TempDir()
+	fs := NewLocalFS(root)
+
+	w, err := fs.Create("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("content"))
+	w.Close()
+
+	if err := fs.Link("a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	aSame, err := os.Stat(filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bSame, err := os.Stat(filepath.Join(root, "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(aSame, bSame) {
+		t.Fatal("expected a.txt and b.txt to be hard-linked")
+	}
+}
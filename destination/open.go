@@ -0,0 +1,43 @@
+package destination
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+)
+
+// Open resolves target into a Destination. A plain path is local;
+// sftp://user@host/path dials an SFTP server (using knownHostsPath for
+// host key verification and privateKeyPath for auth, falling back to the
+// ssh-agent when privateKeyPath is empty); s3://bucket/prefix talks to an
+// S3-compatible bucket using the standard AWS credential chain.
+func Open(ctx context.Context, target string, knownHostsPath, privateKeyPath string) (Destination, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" {
+		return NewLocalFS(target), nil
+	}
+
+	switch u.Scheme {
+	case "sftp":
+		port := u.Port()
+		if port == "" {
+			port = "22"
+		}
+		return DialSFTP(SFTPConfig{
+			Addr:           fmt.Sprintf("%s:%s", u.Hostname(), port),
+			User:           u.User.Username(),
+			PrivateKeyPath: privateKeyPath,
+			KnownHostsPath: knownHostsPath,
+			Root:           u.Path,
+		})
+	case "s3":
+		prefix := u.Path
+		if prefix != "" {
+			prefix = path.Clean(prefix)
+		}
+		return NewS3(ctx, S3Config{Bucket: u.Host, Prefix: prefix})
+	default:
+		return nil, fmt.Errorf("destination: unsupported scheme %q in %q", u.Scheme, target)
+	}
+}
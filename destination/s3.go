@@ -0,0 +1,208 @@
+package destination
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Config describes the bucket an S3 destination writes to and how it
+// uploads.
+type S3Config struct {
+	Bucket string
+	Prefix string
+	Region string
+
+	// MultipartThreshold is the file size (bytes) above which uploads are
+	// split into multipart parts. Zero uses the SDK manager's default
+	// (currently 5 MiB per part).
+	MultipartThreshold int64
+
+	// ServerSideEncryption selects SSE mode, e.g. "AES256" or "aws:kms".
+	// Empty disables server-side encryption.
+	ServerSideEncryption string
+}
+
+// S3 implements Destination and Source over an S3-compatible bucket,
+// uploading through aws-sdk-go-v2's manager.Uploader so files above
+// MultipartThreshold are split into multipart parts automatically.
+type S3 struct {
+	client     *s3.Client
+	uploader   *manager.Uploader
+	downloader *manager.Downloader
+	cfg        S3Config
+}
+
+// NewS3 loads AWS credentials and config the standard SDK way (env vars,
+// shared config/credentials files, or an attached role) and returns an S3
+// destination for cfg.Bucket/cfg.Prefix.
+func NewS3(ctx context.Context, cfg S3Config) (*S3, error) {
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(awsCfg)
+
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		if cfg.MultipartThreshold > 0 {
+			u.PartSize = cfg.MultipartThreshold
+		}
+	})
+
+	return &S3{
+		client:     client,
+		uploader:   uploader,
+		downloader: manager.NewDownloader(client),
+		cfg:        cfg,
+	}, nil
+}
+
+func (s *S3) key(p string) string {
+	return path.Join(s.cfg.Prefix, p)
+}
+
+// Mkdir is a no-op: S3 has no real directories, only key prefixes.
+func (s *S3) Mkdir(string) error { return nil }
+
+// Create returns a writer that streams into S3 via a pipe backed by a
+// multipart upload; the upload only completes (and any error surfaces)
+// once the returned writer is closed.
+func (s *S3) Create(p string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.key(p)),
+		Body:   pr,
+	}
+	if s.cfg.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(s.cfg.ServerSideEncryption)
+	}
+
+	go func() {
+		_, err := s.uploader.Upload(context.Background(), input)
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &s3WriteCloser{pw: pw, done: done}, nil
+}
+
+// s3WriteCloser adapts the write side of an io.Pipe into the io.WriteCloser
+// Destination.Create expects, surfacing the upload goroutine's error (if
+// any) from Close.
+type s3WriteCloser struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+func (w *s3WriteCloser) Write(p []byte) (int, error) { return w.pw.Write(p) }
+
+func (w *s3WriteCloser) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+func (s *S3) Open(p string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.key(p)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *S3) Stat(p string) (os.FileInfo, error) {
+	out, err := s.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.key(p)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	if out.ContentLength != nil {
+		size = *out.ContentLength
+	}
+	var modTime time.Time
+	if out.LastModified != nil {
+		modTime = *out.LastModified
+	}
+	return s3FileInfo{name: path.Base(p), size: size, modTime: modTime}, nil
+}
+
+func (s *S3) List(p string) ([]os.FileInfo, error) {
+	prefix := s.key(p)
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	var infos []os.FileInfo
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.cfg.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			var size int64
+			if obj.Size != nil {
+				size = *obj.Size
+			}
+			var modTime time.Time
+			if obj.LastModified != nil {
+				modTime = *obj.LastModified
+			}
+			infos = append(infos, s3FileInfo{name: path.Base(*obj.Key), size: size, modTime: modTime})
+		}
+		for _, p := range page.CommonPrefixes {
+			infos = append(infos, s3FileInfo{name: path.Base(strings.TrimSuffix(*p.Prefix, "/")), isDir: true})
+		}
+	}
+	return infos, nil
+}
+
+// Link always fails: S3 objects have no hard-link equivalent, so callers
+// should fall back to a full copy when Link returns ErrLinkUnsupported.
+func (s *S3) Link(string, string) error {
+	return ErrLinkUnsupported
+}
+
+// s3FileInfo is a minimal os.FileInfo for objects and common prefixes
+// returned by S3, which doesn't have real file metadata like mode bits.
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (i s3FileInfo) Name() string       { return i.name }
+func (i s3FileInfo) Size() int64        { return i.size }
+func (i s3FileInfo) ModTime() time.Time { return i.modTime }
+func (i s3FileInfo) IsDir() bool        { return i.isDir }
+func (i s3FileInfo) Sys() any           { return nil }
+func (i s3FileInfo) Mode() os.FileMode {
+	if i.isDir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
@@ -1,49 +1,71 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
-	"os"
-	"path/filepath"
+
+	"github.com/GraphiteSprite/disk-hog-backup/archive"
+	"github.com/GraphiteSprite/disk-hog-backup/destination"
 )
 
 func main() {
 }
 
+// backup creates a new snapshot of source under dest. Files that are
+// unchanged since the previous snapshot are hard-linked rather than
+// re-copied, and identical file contents anywhere in source are deduped
+// against the destination's content index. The source tree is walked once
+// and copied by a pool of concurrent workers rather than serially.
 func backup(source string, dest string) {
 	fmt.Printf("backing up %v into %v\n", source, dest)
-	contents, err := ioutil.ReadDir(source)
+
+	root := newSnapshotRootWithPool(dest, PoolOptions{MaxConcurrency: 4})
+	snap, err := root.Create(context.Background(), source)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("contents %#v\n", contents)
-	for _, item := range contents {
-		itemPath := filepath.Join(source, item.Name())
-		destFile := filepath.Join(dest, item.Name())
-		copyFile(itemPath, destFile)
-	}
+
+	fmt.Printf("created snapshot %v\n", snap.ID)
 }
 
-func copyFile(source string, dest string) {
-	fmt.Printf("copying %v to : %v\n", source, dest)
+// archiveBackup is the single-file alternative to backup: it writes source
+// as one gzip-compressed, optionally encrypted .dhb file instead of a
+// directory of snapshots.
+func archiveBackup(source string, destFile string, passphrase string) {
+	fmt.Printf("archiving %v into %v\n", source, destFile)
 
-	srcFile, err := os.Open(source)
-	if err != nil {
+	opts := archive.ArchiveOptions{Compress: true}
+	if passphrase != "" {
+		opts.Encrypt = true
+		opts.Passphrase = passphrase
+	}
+
+	if err := archive.Archive(source, destFile, opts); err != nil {
 		log.Fatal(err)
 	}
-	defer srcFile.Close()
 
-	destFile, err := os.Create(dest)
+	fmt.Printf("created archive %v\n", destFile)
+}
+
+// backupToTarget copies source into dest, where dest is a local path,
+// sftp://user@host/path, or s3://bucket/prefix. Unlike backup, this path
+// doesn't hard-link against a previous snapshot when dest can't support
+// links (SFTP servers without the hardlink extension, and S3, fall back
+// to a full copy via destination.LinkOrCopy).
+func backupToTarget(source string, dest string, knownHostsPath, privateKeyPath string) {
+	fmt.Printf("backing up %v into %v\n", source, dest)
+
+	ctx := context.Background()
+	target, err := destination.Open(ctx, dest, knownHostsPath, privateKeyPath)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer destFile.Close()
 
-	bytesWritten, err := io.Copy(destFile, srcFile)
-	if err != nil {
+	src := destination.NewLocalFS(source)
+	if err := destination.CopyTree(src, ".", target, "."); err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("%v bytes copied", bytesWritten)
+
+	fmt.Printf("backed up %v into %v\n", source, dest)
 }
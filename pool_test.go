@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingProgress records every BytesPerSecond report it receives, so
+// tests can assert pooledCopier.Copy actually drives a throughputReporter.
+type recordingProgress struct {
+	noopProgress
+	mu    sync.Mutex
+	rates []float64
+}
+
+func (p *recordingProgress) BytesPerSecond(rate float64) {
+	p.mu.Lock()
+	p.rates = append(p.rates, rate)
+	p.mu.Unlock()
+}
+
+func (p *recordingProgress) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.rates)
+}
+
+func TestPooledCopierCopiesAllFiles(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	for i := 0; i < 5; i++ {
+		name := filepath.Join(src, string(rune('a'+i))+".txt")
+		if err := os.WriteFile(name, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copier := newPooledCopier(NewCopyEngine(), PoolOptions{MaxConcurrency: 3})
+	errs := copier.Copy(context.Background(), src, dest, DefaultOptions())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	for i := 0; i < 5; i++ {
+		name := string(rune('a'+i)) + ".txt"
+		if _, err := os.Stat(filepath.Join(dest, name)); err != nil {
+			t.Fatalf("expected %s to be copied: %v", name, err)
+		}
+	}
+}
+
+func TestPooledCopierReportsThroughput(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := &recordingProgress{}
+	copier := newPooledCopier(NewCopyEngine(), PoolOptions{MaxConcurrency: 1, Progress: progress})
+	errs := copier.Copy(context.Background(), src, dest, DefaultOptions())
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if progress.count() == 0 {
+		t.Fatal("expected Copy to report at least one BytesPerSecond sample")
+	}
+}
+
+func TestPooledCopierCancelsOnContext(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	copier := newPooledCopier(NewCopyEngine(), PoolOptions{MaxConcurrency: 2})
+	errs := copier.Copy(ctx, src, dest, DefaultOptions())
+	if len(errs) == 0 {
+		t.Fatal("expected cancellation error")
+	}
+}
+
+func TestThroughputReporterReportsRate(t *testing.T) {
+	progress := &recordingProgress{}
+	reporter := newThroughputReporter(progress)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go reporter.run(ctx, 10*time.Millisecond)
+
+	reporter.add(1024)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if progress.count() == 0 {
+		t.Fatal("expected at least one BytesPerSecond report")
+	}
+}
+
+func TestTokenBucketThrottles(t *testing.T) {
+	tb := newTokenBucket(1024)
+	if err := tb.take(context.Background(), 512); err != nil {
+		t.Fatal(err)
+	}
+	if tb.tokens > 1024 {
+		t.Fatalf("tokens exceeded capacity: %v", tb.tokens)
+	}
+}
@@ -0,0 +1,630 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// snapshotTimeFormat is used for both naming snapshot directories and
+// parsing them back into time.Time for sorting and retention decisions.
+// Nanosecond precision keeps back-to-back Create calls from landing on the
+// same directory; List still falls back to parsing the "_N" collision
+// suffix (see uniqueSnapshotDir) off of the part before it.
+const snapshotTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// indexFileName is the name of the content index written inside every
+// snapshot directory, used to dedupe file contents via hard-linking.
+const indexFileName = ".dhb-index.json"
+
+// fileRecord describes one backed-up file's content identity, keyed by
+// hash+mtime+size so that unchanged files can be hard-linked instead of
+// re-copied, and identical files elsewhere in the tree can be deduped too.
+type fileRecord struct {
+	Path    string      `json:"path"`
+	Hash    string      `json:"hash"`
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mod_time"`
+	Mode    os.FileMode `json:"mode"`
+}
+
+// index maps a content key (hash+size+mtime) to the snapshot-relative path
+// that holds the canonical copy of that content.
+type index struct {
+	Records []fileRecord `json:"records"`
+
+	byKey  map[string]string
+	byPath map[string]fileRecord
+}
+
+func newIndex() *index {
+	return &index{
+		byKey:  map[string]string{},
+		byPath: map[string]fileRecord{},
+	}
+}
+
+func (idx *index) key(rec fileRecord) string {
+	return fmt.Sprintf("%s:%d:%d", rec.Hash, rec.Size, rec.ModTime.UnixNano())
+}
+
+func (idx *index) add(rec fileRecord) {
+	idx.Records = append(idx.Records, rec)
+	idx.byKey[idx.key(rec)] = rec.Path
+	idx.byPath[rec.Path] = rec
+}
+
+// lookup returns the snapshot-relative path already holding this content, if
+// any.
+func (idx *index) lookup(rec fileRecord) (string, bool) {
+	p, ok := idx.byKey[idx.key(rec)]
+	return p, ok
+}
+
+func loadIndex(snapshotDir string) (*index, error) {
+	idx := newIndex()
+	data, err := os.ReadFile(filepath.Join(snapshotDir, indexFileName))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []fileRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		idx.add(rec)
+	}
+	return idx, nil
+}
+
+func (idx *index) save(snapshotDir string) error {
+	data, err := json.MarshalIndent(idx.Records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(snapshotDir, indexFileName), data, 0o644)
+}
+
+// Retention is a grandfather-father-son policy: the most recent KeepDaily
+// snapshots are kept outright (the "son" tier), then thinned down to one
+// snapshot per ISO week for the next KeepWeekly weeks (the "father" tier),
+// then to one snapshot per calendar month for the next KeepMonthly months
+// (the "grandfather" tier).
+type Retention struct {
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+}
+
+// Snapshot represents a single point-in-time backup of a source tree,
+// stored as a timestamped directory under a destination root.
+type Snapshot struct {
+	ID   string
+	Dir  string
+	Time time.Time
+}
+
+// snapshotRoot is the destination directory under which all snapshots for
+// a given backup target are stored.
+type snapshotRoot struct {
+	dest   string
+	engine CopyEngine
+	pool   PoolOptions
+}
+
+func newSnapshotRoot(dest string) *snapshotRoot {
+	return &snapshotRoot{dest: dest, engine: NewCopyEngine(), pool: PoolOptions{MaxConcurrency: 1}}
+}
+
+// newSnapshotRootWithPool returns a snapshotRoot whose Create fans file
+// copies out to pool (concurrency, rate limit, and progress reporting) in
+// place of copying one file at a time.
+func newSnapshotRootWithPool(dest string, pool PoolOptions) *snapshotRoot {
+	if pool.MaxConcurrency <= 0 {
+		pool.MaxConcurrency = 1
+	}
+	if pool.Progress == nil {
+		pool.Progress = noopProgress{}
+	}
+	return &snapshotRoot{dest: dest, engine: NewCopyEngine(), pool: pool}
+}
+
+// List returns all snapshots found under dest, oldest first.
+func (r *snapshotRoot) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(r.dest)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []Snapshot
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		t, ok := parseSnapshotID(e.Name())
+		if !ok {
+			continue
+		}
+		snaps = append(snaps, Snapshot{
+			ID:   e.Name(),
+			Dir:  filepath.Join(r.dest, e.Name()),
+			Time: t,
+		})
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Time.Before(snaps[j].Time) })
+	return snaps, nil
+}
+
+// parseSnapshotID parses a directory name produced by uniqueSnapshotDir back
+// into the time it was created at, ignoring any "_N" collision suffix.
+func parseSnapshotID(id string) (time.Time, bool) {
+	if t, err := time.Parse(snapshotTimeFormat, id); err == nil {
+		return t, true
+	}
+	if i := strings.LastIndex(id, "_"); i != -1 {
+		if t, err := time.Parse(snapshotTimeFormat, id[:i]); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// uniqueSnapshotDir creates and returns a snapshot ID/directory pair for now
+// under destRoot. Nanosecond-precision timestamps make collisions
+// vanishingly rare, but a coarse monotonic clock, a very tight test loop, or
+// two overlapping Create calls could still produce one, so this claims the
+// directory with os.Mkdir (which fails if it already exists, unlike
+// MkdirAll) and retries with a "_N" suffix on collision instead of merely
+// checking os.Stat first, which would leave a race between the check and
+// the later MkdirAll in Create.
+func uniqueSnapshotDir(destRoot string, now time.Time) (id string, dir string, err error) {
+	if err := os.MkdirAll(destRoot, 0o755); err != nil {
+		return "", "", err
+	}
+	base := now.Format(snapshotTimeFormat)
+	id = base
+	dir = filepath.Join(destRoot, id)
+	for n := 1; ; n++ {
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			return id, dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", "", err
+		}
+		id = fmt.Sprintf("%s_%d", base, n)
+		dir = filepath.Join(destRoot, id)
+	}
+}
+
+// latest returns the most recent snapshot, if one exists.
+func (r *snapshotRoot) latest() (*Snapshot, error) {
+	snaps, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(snaps) == 0 {
+		return nil, nil
+	}
+	return &snaps[len(snaps)-1], nil
+}
+
+// snapshotJob is one file discovered while walking the source tree, queued
+// for a snapshot worker to hash and either hard-link or copy.
+type snapshotJob struct {
+	path     string
+	destPath string
+	rel      string
+	info     os.FileInfo
+}
+
+// Create makes a new snapshot of source under dest, hard-linking any file
+// whose content already exists in the previous snapshot or elsewhere in the
+// index rather than copying it. The source tree is walked once; the
+// resulting file jobs are fanned out to r.pool.MaxConcurrency workers so a
+// serial walk doesn't leave disk or network bandwidth unused. ctx governs
+// cancellation: once it is done, no new jobs are started and Create returns
+// ctx.Err() alongside any errors already collected.
+func (r *snapshotRoot) Create(ctx context.Context, source string) (Snapshot, error) {
+	now := time.Now()
+
+	// latest must be resolved before claiming this snapshot's own
+	// directory below, or List would see the (still-empty) new directory
+	// and treat it as its own predecessor.
+	prev, err := r.latest()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var prevIdx *index
+	var prevDir string
+	if prev != nil {
+		prevDir = prev.Dir
+		prevIdx, err = loadIndex(prevDir)
+		if err != nil {
+			return Snapshot{}, err
+		}
+	} else {
+		prevIdx = newIndex()
+	}
+
+	id, dir, err := uniqueSnapshotDir(r.dest, now)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snap := Snapshot{ID: id, Dir: dir, Time: now}
+
+	jobs := make(chan snapshotJob)
+	walkErrCh := make(chan error, 1)
+	go func() {
+		defer close(jobs)
+		walkErrCh <- r.walkSnapshotJobs(ctx, source, snap.Dir, jobs)
+	}()
+
+	idx := newIndex()
+	var idxMu sync.Mutex
+	var errsMu sync.Mutex
+	var errs []error
+	pending := newPendingClaims()
+
+	concurrency := r.pool.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var limiter *tokenBucket
+	if r.pool.RateLimit > 0 {
+		limiter = newTokenBucket(r.pool.RateLimit)
+	}
+	progress := r.pool.Progress
+	if progress == nil {
+		progress = noopProgress{}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				progress.FileStarted(job.path)
+				rec, n, err := r.snapshotFile(job.path, job.destPath, job.rel, job.info, snap.Dir, prevDir, prevIdx, pending, limiter)
+				progress.FileFinished(job.path, n, err)
+				if err != nil {
+					errsMu.Lock()
+					errs = append(errs, err)
+					errsMu.Unlock()
+					continue
+				}
+				idxMu.Lock()
+				idx.add(rec)
+				idxMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := <-walkErrCh; err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return Snapshot{}, errs[0]
+	}
+
+	if err := idx.save(snap.Dir); err != nil {
+		return Snapshot{}, err
+	}
+
+	return snap, nil
+}
+
+// walkSnapshotJobs walks source once, creating the matching directory
+// structure under snapDir inline and sending one job per regular file to
+// jobs. It stops and returns ctx.Err() as soon as ctx is done.
+func (r *snapshotRoot) walkSnapshotJobs(ctx context.Context, source, snapDir string, jobs chan<- snapshotJob) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		destPath := filepath.Join(snapDir, rel)
+		if info.IsDir() {
+			return os.MkdirAll(destPath, info.Mode())
+		}
+
+		select {
+		case jobs <- snapshotJob{path: path, destPath: destPath, rel: rel, info: info}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// pendingClaims dedupes identical-content files discovered within a single
+// Create run: the first worker to hash a given content key copies it, and
+// any other worker that hashes the same content waits for that copy to land
+// and hard-links to it instead of copying the content again. Without this,
+// prevIdx alone would only catch duplicates that already existed in the
+// previous snapshot, missing duplicates introduced or first seen in the
+// current run.
+type pendingClaims struct {
+	mu     sync.Mutex
+	claims map[string]*pendingClaim
+}
+
+// pendingClaim is the state shared between the worker that owns a content
+// key and any workers waiting on it. ready is closed once the owner's copy
+// attempt finishes; path is only meaningful after that, and is empty if the
+// owner's copy failed (in which case waiters must copy the content
+// themselves).
+type pendingClaim struct {
+	ready chan struct{}
+	path  string
+}
+
+func newPendingClaims() *pendingClaims {
+	return &pendingClaims{claims: map[string]*pendingClaim{}}
+}
+
+// acquire returns the claim for key. owner is true if the caller is the
+// first to claim key and must copy the content itself, then call
+// claim.finish. Otherwise the caller should wait on claim.ready before
+// consulting claim.path.
+func (p *pendingClaims) acquire(key string) (claim *pendingClaim, owner bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if existing, ok := p.claims[key]; ok {
+		return existing, false
+	}
+	claim = &pendingClaim{ready: make(chan struct{})}
+	p.claims[key] = claim
+	return claim, true
+}
+
+// finish records the snapshot-relative path of the owner's copy (empty on
+// failure) and wakes any workers waiting on this claim.
+func (c *pendingClaim) finish(rel string) {
+	c.path = rel
+	close(c.ready)
+}
+
+// snapshotFile links path into the snapshot if an identical copy already
+// exists in the previous snapshot or has already been copied elsewhere in
+// this same run (by content hash+size+mtime), otherwise copies it fresh
+// through the snapshot root's CopyEngine.
+func (r *snapshotRoot) snapshotFile(path, destPath, rel string, info os.FileInfo, snapDir, prevDir string, prevIdx *index, pending *pendingClaims, limiter *tokenBucket) (fileRecord, int64, error) {
+	hash, err := hashFile(path)
+	if err != nil {
+		return fileRecord{}, 0, err
+	}
+	rec := fileRecord{
+		Path:    rel,
+		Hash:    hash,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return fileRecord{}, 0, err
+	}
+
+	if prior, ok := prevIdx.lookup(rec); ok && prevDir != "" {
+		priorPath := filepath.Join(prevDir, prior)
+		if linkErr := os.Link(priorPath, destPath); linkErr == nil {
+			return rec, rec.Size, nil
+		}
+	}
+
+	claim, owner := pending.acquire(prevIdx.key(rec))
+	if !owner {
+		<-claim.ready
+		if claim.path != "" {
+			if linkErr := os.Link(filepath.Join(snapDir, claim.path), destPath); linkErr == nil {
+				return rec, rec.Size, nil
+			}
+		}
+	}
+
+	n, copyErr := r.copyFile(path, destPath, info, limiter)
+	if owner {
+		if copyErr == nil {
+			claim.finish(rel)
+		} else {
+			claim.finish("")
+		}
+	}
+	if copyErr != nil {
+		return fileRecord{}, 0, copyErr
+	}
+	return rec, n, nil
+}
+
+// copyFile copies path to destPath, through a rate limiter when one is
+// configured.
+func (r *snapshotRoot) copyFile(path, destPath string, info os.FileInfo, limiter *tokenBucket) (int64, error) {
+	if limiter == nil {
+		if err := r.engine.Copy(path, destPath, DefaultOptions()); err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	if err := copyRateLimited(context.Background(), path, destPath, info, limiter); err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// copyRateLimited copies a single regular file through a tokenBucket, used
+// when a snapshot's pool is configured with a RateLimit.
+func copyRateLimited(ctx context.Context, src, dest string, info os.FileInfo, limiter *tokenBucket) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	if _, err := io.Copy(destFile, &rateLimitedReader{ctx: ctx, r: srcFile, limiter: limiter}); err != nil {
+		return err
+	}
+	destFile.Close()
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Prune removes all but the most recent keep snapshots.
+func (r *snapshotRoot) Prune(keep int) error {
+	snaps, err := r.List()
+	if err != nil {
+		return err
+	}
+	if len(snaps) <= keep {
+		return nil
+	}
+	for _, s := range snaps[:len(snaps)-keep] {
+		if err := os.RemoveAll(s.Dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyRetention removes snapshots that fall outside the grandfather-father-
+// son policy, keeping the most recent KeepDaily snapshots outright, then
+// thinning older ones to one-per-ISO-week and one-per-calendar-month.
+func (r *snapshotRoot) ApplyRetention(policy Retention) error {
+	snaps, err := r.List()
+	if err != nil {
+		return err
+	}
+	keep := map[string]bool{}
+
+	// Newest-first for the thinning passes below.
+	reversed := make([]Snapshot, len(snaps))
+	for i, s := range snaps {
+		reversed[len(snaps)-1-i] = s
+	}
+
+	for i, s := range reversed {
+		if i < policy.KeepDaily {
+			keep[s.ID] = true
+		}
+	}
+
+	seenWeeks := map[string]bool{}
+	weekBudget := policy.KeepWeekly
+	for _, s := range reversed {
+		year, week := s.Time.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%02d", year, week)
+		if seenWeeks[weekKey] {
+			continue
+		}
+		seenWeeks[weekKey] = true
+		if weekBudget <= 0 {
+			break
+		}
+		keep[s.ID] = true
+		weekBudget--
+	}
+
+	seenMonths := map[string]bool{}
+	monthBudget := policy.KeepMonthly
+	for _, s := range reversed {
+		monthKey := s.Time.Format("2006-01")
+		if seenMonths[monthKey] {
+			continue
+		}
+		seenMonths[monthKey] = true
+		if monthBudget <= 0 {
+			break
+		}
+		keep[s.ID] = true
+		monthBudget--
+	}
+
+	for _, s := range snaps {
+		if keep[s.ID] {
+			continue
+		}
+		if err := os.RemoveAll(s.Dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore copies the contents of the snapshot identified by id into dest.
+func (r *snapshotRoot) Restore(id string, dest string) error {
+	snapDir := filepath.Join(r.dest, id)
+	if _, err := os.Stat(snapDir); err != nil {
+		return fmt.Errorf("snapshot %s not found: %w", id, err)
+	}
+	return filepath.Walk(snapDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(snapDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		if rel == indexFileName {
+			return nil
+		}
+		destPath := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(destPath, info.Mode())
+		}
+		if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+			return err
+		}
+		return r.engine.Copy(path, destPath, DefaultOptions())
+	})
+}
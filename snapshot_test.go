@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mkSnapshotAt creates an empty snapshot directory under dest timestamped at
+// tm, without going through Create, so ApplyRetention can be tested against
+// a synthetic multi-day/multi-week set of snapshots rather than whatever
+// wall-clock time the test happens to run at.
+func mkSnapshotAt(t *testing.T, dest string, tm time.Time) string {
+	t.Helper()
+	id, _, err := uniqueSnapshotDir(dest, tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestSnapshotCreateDedupesUnchangedFiles(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := newSnapshotRoot(dest)
+	first, err := root.Create(context.Background(), src)
+	if err != nil {
+		t.Fatalf("first snapshot: %v", err)
+	}
+
+	second, err := root.Create(context.Background(), src)
+	if err != nil {
+		t.Fatalf("second snapshot: %v", err)
+	}
+	if first.Dir == second.Dir {
+		t.Fatalf("expected distinct snapshot directories, both got %q", first.Dir)
+	}
+
+	firstInfo, err := os.Stat(filepath.Join(first.Dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondInfo, err := os.Stat(filepath.Join(second.Dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(firstInfo, secondInfo) {
+		t.Fatal("expected unchanged file to be hard-linked across snapshots")
+	}
+}
+
+func TestSnapshotCreateDedupesIdenticalFilesWithinOneRun(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte("same content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := newSnapshotRoot(dest)
+	snap, err := root.Create(context.Background(), src)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	aInfo, err := os.Stat(filepath.Join(snap.Dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bInfo, err := os.Stat(filepath.Join(snap.Dir, "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(aInfo, bInfo) {
+		t.Fatal("expected identical-content files added in the same run to be hard-linked to each other")
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	src := t.TempDir()
+	dest := t.TempDir()
+	restoreDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := newSnapshotRoot(dest)
+	snap, err := root.Create(context.Background(), src)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := root.Restore(snap.ID, restoreDir); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(restoreDir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("got %q, want %q", data, "hello")
+	}
+}
+
+func TestApplyRetentionKeepsMostRecentPerWeek(t *testing.T) {
+	dest := t.TempDir()
+	root := newSnapshotRoot(dest)
+	// A Monday, so weekStart+{0,2,4} days below never crosses an ISO week
+	// boundary.
+	monday := time.Date(2026, 7, 20, 10, 0, 0, 0, time.UTC)
+
+	var weekIDs [5][]string
+	for week := 0; week < 5; week++ {
+		weekStart := monday.AddDate(0, 0, (week-4)*7)
+		for _, dayOffset := range []int{0, 2, 4} {
+			tm := weekStart.AddDate(0, 0, dayOffset)
+			weekIDs[week] = append(weekIDs[week], mkSnapshotAt(t, dest, tm))
+		}
+	}
+
+	// KeepDaily=1 keeps only the single most recent snapshot outright; the
+	// weekly-thinning pass then keeps the most recent snapshot of each of
+	// the next 3 ISO weeks (weeks 4, 3, 2), collapsing the other two per
+	// week.
+	if err := root.ApplyRetention(Retention{KeepDaily: 1, KeepWeekly: 3, KeepMonthly: 0}); err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+
+	snaps, err := root.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("got %d snapshots, want 3", len(snaps))
+	}
+	want := map[string]bool{
+		weekIDs[4][2]: true,
+		weekIDs[3][2]: true,
+		weekIDs[2][2]: true,
+	}
+	for _, s := range snaps {
+		if !want[s.ID] {
+			t.Fatalf("kept unexpected snapshot %q", s.ID)
+		}
+	}
+}
+
+func TestApplyRetentionThinsToOnePerCalendarMonth(t *testing.T) {
+	dest := t.TempDir()
+	root := newSnapshotRoot(dest)
+	// July 2026, the most recent of 5 consecutive months.
+	july := time.Date(2026, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	var monthIDs [5][]string
+	for month := 0; month < 5; month++ {
+		monthStart := july.AddDate(0, -(4 - month), 0)
+		for _, day := range []int{5, 15, 25} {
+			tm := time.Date(monthStart.Year(), monthStart.Month(), day, 10, 0, 0, 0, time.UTC)
+			monthIDs[month] = append(monthIDs[month], mkSnapshotAt(t, dest, tm))
+		}
+	}
+
+	// KeepDaily=1 keeps only the single most recent snapshot outright; the
+	// monthly-thinning pass then keeps the most recent snapshot of each of
+	// the next 3 calendar months (months 4, 3, 2), collapsing the other two
+	// per month. KeepWeekly=0 so the weekly tier contributes nothing.
+	if err := root.ApplyRetention(Retention{KeepDaily: 1, KeepWeekly: 0, KeepMonthly: 3}); err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+
+	snaps, err := root.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("got %d snapshots, want 3", len(snaps))
+	}
+	want := map[string]bool{
+		monthIDs[4][2]: true,
+		monthIDs[3][2]: true,
+		monthIDs[2][2]: true,
+	}
+	for _, s := range snaps {
+		if !want[s.ID] {
+			t.Fatalf("kept unexpected snapshot %q", s.ID)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dest := t.TempDir()
+	src := t.TempDir()
+
+	root := newSnapshotRoot(dest)
+	dirs := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		snap, err := root.Create(context.Background(), src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs[snap.Dir] = true
+	}
+	if len(dirs) != 3 {
+		t.Fatalf("expected 3 distinct snapshot directories, got %d", len(dirs))
+	}
+
+	if err := root.Prune(1); err != nil {
+		t.Fatal(err)
+	}
+
+	snaps, err := root.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 1 {
+		t.Fatalf("got %d snapshots, want 1", len(snaps))
+	}
+}